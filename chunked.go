@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/settermjd/go-qr-code-generator/qrcode"
+	"github.com/settermjd/go-qr-code-generator/qrstream"
+)
+
+// ChunkedQRCodeSize is the pixel size used for each frame of a chunked QR
+// code response.
+const ChunkedQRCodeSize = 512
+
+// DefaultChunkSize is the number of bytes of the original message encoded
+// per QR code frame, when the client doesn't request a specific size.
+const DefaultChunkSize = 200
+
+// DefaultChunkedFPS is the frame rate used for an animated GIF response
+// when the client doesn't request one.
+const DefaultChunkedFPS = 2
+
+// handleGenerateChunked splits oversized content into a series of QR codes
+// via the qrstream package, returning either one PNG per chunk in a
+// multipart response or, if animate=gif is requested, an animated GIF
+// cycling through the frames.
+func handleGenerateChunked(writer http.ResponseWriter, request *http.Request) {
+	request.ParseMultipartForm(10 << 20)
+
+	content := request.FormValue("content")
+	if content == "" {
+		writeJSONError(writer, http.StatusBadRequest, "Could not determine the desired QR code content.")
+		return
+	}
+
+	chunkSize, err := strconv.Atoi(request.FormValue("chunk_size"))
+	if err != nil || chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+
+	envelopes, err := qrstream.NewEnvelopes(uuid.NewString(), qrstream.SplitIntoChunks([]byte(content), chunkSize))
+	if err != nil {
+		writeJSONError(writer, http.StatusInternalServerError, fmt.Sprintf("Could not split content into chunks. %v", err))
+		return
+	}
+
+	frames := make([][]byte, 0, len(envelopes))
+	for index, envelope := range envelopes {
+		qrCode := qrcode.SimpleQRCode{Content: string(envelope), Size: ChunkedQRCodeSize, EncodeLevel: qrcode.Medium}
+		frame, err := qrCode.Generate()
+		if err != nil {
+			writeJSONError(writer, http.StatusInternalServerError, fmt.Sprintf("Could not generate QR code for chunk %d. %v", index, err))
+			return
+		}
+		frames = append(frames, frame)
+	}
+
+	if request.FormValue("animate") == "gif" {
+		writeChunkedGIF(writer, frames, request.FormValue("fps"))
+		return
+	}
+
+	writeChunkedMultipart(writer, frames)
+}
+
+// writeChunkedMultipart writes one PNG part per chunk frame.
+func writeChunkedMultipart(writer http.ResponseWriter, frames [][]byte) {
+	body := bytes.NewBuffer(nil)
+	mw := multipart.NewWriter(body)
+
+	for index, frame := range frames {
+		part, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":        {"image/png"},
+			"Content-Disposition": {fmt.Sprintf(`form-data; name="chunk%d"; filename="chunk%d.png"`, index, index)},
+		})
+		if err != nil {
+			writeJSONError(writer, http.StatusInternalServerError, fmt.Sprintf("Could not write chunk %d. %v", index, err))
+			return
+		}
+		part.Write(frame)
+	}
+	mw.Close()
+
+	writer.Header().Set("Content-Type", mw.FormDataContentType())
+	writer.Write(body.Bytes())
+}
+
+// writeChunkedGIF encodes the chunk frames as an animated GIF, cycling
+// through them at the requested (or default) frame rate.
+func writeChunkedGIF(writer http.ResponseWriter, frames [][]byte, fpsParam string) {
+	fps, err := strconv.Atoi(fpsParam)
+	if err != nil || fps <= 0 {
+		fps = DefaultChunkedFPS
+	}
+	delay := 100 / fps // gif.GIF.Delay is in hundredths of a second
+
+	anim := gif.GIF{}
+	for index, frame := range frames {
+		img, err := png.Decode(bytes.NewReader(frame))
+		if err != nil {
+			writeJSONError(writer, http.StatusInternalServerError, fmt.Sprintf("Could not decode chunk %d for animation. %v", index, err))
+			return
+		}
+
+		paletted := image.NewPaletted(img.Bounds(), palette.Plan9)
+		draw.Draw(paletted, img.Bounds(), img, image.Point{}, draw.Src)
+
+		anim.Image = append(anim.Image, paletted)
+		anim.Delay = append(anim.Delay, delay)
+	}
+
+	writer.Header().Set("Content-Type", "image/gif")
+	if err := gif.EncodeAll(writer, &anim); err != nil {
+		log.Println("could not encode animated GIF:", err)
+	}
+}
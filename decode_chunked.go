@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	tuotooqrcode "github.com/tuotoo/qrcode"
+
+	"github.com/settermjd/go-qr-code-generator/qrstream"
+)
+
+// handleDecodeChunked accepts one or more uploaded QR code frames produced
+// by /generate/chunked, decodes each with the tuotoo/qrcode scanner, and
+// reassembles the original content via qrstream.AssembleChunks.
+func handleDecodeChunked(writer http.ResponseWriter, request *http.Request) {
+	if err := request.ParseMultipartForm(MAX_UPLOAD_SIZE); err != nil {
+		writeJSONError(writer, http.StatusBadRequest, fmt.Sprintf("Could not parse multipart form. %v", err))
+		return
+	}
+
+	files := request.MultipartForm.File["frames"]
+	if len(files) == 0 {
+		writeJSONError(writer, http.StatusBadRequest, "No QR code frames were uploaded.")
+		return
+	}
+
+	seenFrames := make(map[[sha256.Size]byte]struct{}, len(files))
+	envelopes := make([][]byte, 0, len(files))
+
+	for _, header := range files {
+		file, err := header.Open()
+		if err != nil {
+			writeJSONError(writer, http.StatusBadRequest, fmt.Sprintf("Could not open uploaded frame %s. %v", header.Filename, err))
+			return
+		}
+
+		data, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			writeJSONError(writer, http.StatusBadRequest, fmt.Sprintf("Could not read uploaded frame %s. %v", header.Filename, err))
+			return
+		}
+
+		// Dedupe identical frames, e.g. the same chunk scanned twice from
+		// an animated GIF, before decoding them.
+		checksum := sha256.Sum256(data)
+		if _, ok := seenFrames[checksum]; ok {
+			continue
+		}
+		seenFrames[checksum] = struct{}{}
+
+		matrix, err := tuotooqrcode.Decode(bytes.NewReader(data))
+		if err != nil {
+			writeJSONError(writer, http.StatusBadRequest, fmt.Sprintf("Could not decode QR code frame %s. %v", header.Filename, err))
+			return
+		}
+
+		envelopes = append(envelopes, []byte(matrix.Content))
+	}
+
+	content, err := qrstream.AssembleChunks(envelopes)
+	if err != nil {
+		writeJSONError(writer, http.StatusBadRequest, fmt.Sprintf("Could not reassemble chunks. %v", err))
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(map[string]string{"content": string(content)})
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	tuotooqrcode "github.com/tuotoo/qrcode"
+
+	"github.com/settermjd/go-qr-code-generator/qrcode"
+	"github.com/settermjd/go-qr-code-generator/qrstream"
+)
+
+// fileEnvelope is the JSON header a file's bytes are wrapped in before
+// being encoded as one or more QR codes.
+type fileEnvelope struct {
+	Name string `json:"name"`
+	Mime string `json:"mime"`
+	Data string `json:"data"`
+}
+
+// baseMediaType strips any parameters (e.g. "; charset=utf-8") from a MIME
+// type, so types that legitimately carry different parameters on the
+// client-declared and sniffed sides can still compare equal. Falls back to
+// contentType unchanged if it isn't parseable as a media type.
+func baseMediaType(contentType string) string {
+	base, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return contentType
+	}
+	return base
+}
+
+// handleEncodeFile base64-encodes an uploaded file's bytes and filename
+// into a fileEnvelope, then encodes it as a single QR code if it fits, or
+// as a chunked series of QR codes (reusing the qrstream subsystem) if it
+// doesn't.
+func handleEncodeFile(writer http.ResponseWriter, request *http.Request) {
+	request.ParseMultipartForm(MAX_UPLOAD_SIZE)
+
+	file, header, err := request.FormFile("file")
+	if err != nil {
+		writeJSONError(writer, http.StatusBadRequest, fmt.Sprintf("Could not read the uploaded file. %v", err))
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, MAX_UPLOAD_SIZE+1))
+	if err != nil {
+		writeJSONError(writer, http.StatusBadRequest, fmt.Sprintf("Could not read the uploaded file. %v", err))
+		return
+	}
+	if len(data) > MAX_UPLOAD_SIZE {
+		writeJSONError(writer, http.StatusBadRequest, "Uploaded file exceeds the maximum upload size.")
+		return
+	}
+
+	level, err := qrcode.ParseRecoveryLevel(request.FormValue("error_correction"))
+	if err != nil {
+		writeJSONError(writer, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Mime is the client-declared Content-Type from the upload, not a sniff
+	// of data: sniffing both here and in handleDecodeFile would compare the
+	// same bytes against themselves and could never catch a mismatch. Only
+	// when the client declared no type is data sniffed instead.
+	declaredMime := header.Header.Get("Content-Type")
+	if declaredMime == "" {
+		declaredMime = http.DetectContentType(data)
+	}
+
+	envelope, err := json.Marshal(fileEnvelope{
+		Name: header.Filename,
+		Mime: declaredMime,
+		Data: base64.StdEncoding.EncodeToString(data),
+	})
+	if err != nil {
+		writeJSONError(writer, http.StatusInternalServerError, fmt.Sprintf("Could not build file envelope. %v", err))
+		return
+	}
+
+	if qrcode.Fits(string(envelope), level) {
+		qrCode := qrcode.SimpleQRCode{Content: string(envelope), Size: ChunkedQRCodeSize, EncodeLevel: level}
+		codeData, err := qrCode.Generate()
+		if err != nil {
+			writeJSONError(writer, http.StatusInternalServerError, fmt.Sprintf("Could not generate QR code. %v", err))
+			return
+		}
+		writeQRCode(writer, qrCode.Format, qrCode.JPEGQuality, codeData)
+		return
+	}
+
+	chunkSize, err := strconv.Atoi(request.FormValue("chunk_size"))
+	if err != nil || chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+
+	envelopes, err := qrstream.NewEnvelopes(uuid.NewString(), qrstream.SplitIntoChunks(envelope, chunkSize))
+	if err != nil {
+		writeJSONError(writer, http.StatusInternalServerError, fmt.Sprintf("Could not split file into chunks. %v", err))
+		return
+	}
+
+	frames := make([][]byte, 0, len(envelopes))
+	for index, chunkEnvelope := range envelopes {
+		qrCode := qrcode.SimpleQRCode{Content: string(chunkEnvelope), Size: ChunkedQRCodeSize, EncodeLevel: level}
+		frame, err := qrCode.Generate()
+		if err != nil {
+			writeJSONError(writer, http.StatusInternalServerError, fmt.Sprintf("Could not generate QR code for chunk %d. %v", index, err))
+			return
+		}
+		frames = append(frames, frame)
+	}
+
+	writeChunkedMultipart(writer, frames)
+}
+
+// handleDecodeFile accepts one or more uploaded QR code PNGs produced by
+// handleEncodeFile, reconstructs the fileEnvelope (reassembling chunks if
+// there's more than one frame), and streams the original file back.
+func handleDecodeFile(writer http.ResponseWriter, request *http.Request) {
+	if err := request.ParseMultipartForm(MAX_UPLOAD_SIZE); err != nil {
+		writeJSONError(writer, http.StatusBadRequest, fmt.Sprintf("Could not parse multipart form. %v", err))
+		return
+	}
+
+	files := request.MultipartForm.File["frames"]
+	if len(files) == 0 {
+		files = request.MultipartForm.File["file"]
+	}
+	if len(files) == 0 {
+		writeJSONError(writer, http.StatusBadRequest, "No QR code frames were uploaded.")
+		return
+	}
+
+	frameContents := make([][]byte, 0, len(files))
+	for _, header := range files {
+		file, err := header.Open()
+		if err != nil {
+			writeJSONError(writer, http.StatusBadRequest, fmt.Sprintf("Could not open uploaded frame %s. %v", header.Filename, err))
+			return
+		}
+
+		data, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			writeJSONError(writer, http.StatusBadRequest, fmt.Sprintf("Could not read uploaded frame %s. %v", header.Filename, err))
+			return
+		}
+
+		matrix, err := tuotooqrcode.Decode(bytes.NewReader(data))
+		if err != nil {
+			writeJSONError(writer, http.StatusBadRequest, fmt.Sprintf("Could not decode QR code frame %s. %v", header.Filename, err))
+			return
+		}
+
+		frameContents = append(frameContents, []byte(matrix.Content))
+	}
+
+	envelopeData := frameContents[0]
+	if len(frameContents) > 1 {
+		var err error
+		envelopeData, err = qrstream.AssembleChunks(frameContents)
+		if err != nil {
+			writeJSONError(writer, http.StatusBadRequest, fmt.Sprintf("Could not reassemble file chunks. %v", err))
+			return
+		}
+	}
+
+	var envelope fileEnvelope
+	if err := json.Unmarshal(envelopeData, &envelope); err != nil {
+		writeJSONError(writer, http.StatusBadRequest, fmt.Sprintf("Could not parse file envelope. %v", err))
+		return
+	}
+
+	data, err := base64.StdEncoding.DecodeString(envelope.Data)
+	if err != nil {
+		writeJSONError(writer, http.StatusBadRequest, fmt.Sprintf("Could not decode file data. %v", err))
+		return
+	}
+
+	// envelope.Mime was declared by the client at encode time (see
+	// handleEncodeFile), so comparing it against a fresh sniff of the
+	// decoded bytes actually catches a file whose content was swapped out
+	// from under its declared type, rather than just reproducing the same
+	// sniff on both sides. Compare base media types only: DetectContentType
+	// adds parameters (e.g. "; charset=utf-8") that a client's declared
+	// type legitimately omits, so a "text/plain" upload still matches. A
+	// declared type that DetectContentType never returns (e.g.
+	// "application/json" or "text/csv", which sniff as "text/plain") is
+	// still reported as a mismatch.
+	if sniffed := baseMediaType(http.DetectContentType(data)); envelope.Mime != "" && sniffed != baseMediaType(envelope.Mime) {
+		writeJSONError(writer, http.StatusBadRequest, fmt.Sprintf("Decoded file content (%s) does not match its declared MIME type (%s).", sniffed, envelope.Mime))
+		return
+	}
+
+	writer.Header().Set("Content-Type", envelope.Mime)
+	writer.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, envelope.Name))
+	writer.Write(data)
+}
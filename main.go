@@ -6,191 +6,284 @@ import (
 	"errors"
 	"flag"
 	"fmt"
-	"image"
-	"image/draw"
-	"image/png"
-	"io"
+	"image/color"
 	"log"
+	"math"
 	"mime/multipart"
 	"net/http"
 	"strconv"
+	"strings"
 
-	"github.com/nfnt/resize"
-	qrcode "github.com/skip2/go-qrcode"
+	"github.com/settermjd/go-qr-code-generator/qrcode"
 )
 
 const MAX_UPLOAD_SIZE = 1024 * 1024 // 1MB
-const WATERMARK_WIDTH = 64
 
-type simpleQRCode struct {
-	Content string
-	Size    int
+// generateRequest is the shape of the JSON body accepted by /generate, as an
+// alternative to the multipart form that has always been supported.
+type generateRequest struct {
+	Content         string `json:"content"`
+	Size            int    `json:"size"`
+	NoMargin        bool   `json:"no_margin"`
+	ErrorCorrection string `json:"error_correction"`
+	OutputFormat    string `json:"output_format"`
+	Quality         int    `json:"quality"`
+	Foreground      string `json:"foreground"`
+	Background      string `json:"background"`
 }
 
-// Generate generates a QR code using the value of simpleQRCode.Content
-func (code *simpleQRCode) Generate() ([]byte, error) {
-	qrCode, err := qrcode.Encode(code.Content, qrcode.Medium, code.Size)
+// buildErrorResponse is a small utility function to simplify returning a JSON response
+// to be returned to the user when an error has occurred
+func buildErrorResponse(message string) []byte {
+	responseData := make(map[string]string)
+	responseData["error"] = message
+
+	response, err := json.Marshal(responseData)
 	if err != nil {
-		return nil, fmt.Errorf("could not generate a QR code: %v", err)
+		log.Fatalln("Could not generate error message.")
 	}
-	return qrCode, nil
+
+	return response
 }
 
-// GenerateWithWatermark generates a QR code using the value of simpleQRCode.Content
-// and adds a watermark to it, centered in the middle of the QR code, using the
-// supplied watermark image data
-func (code *simpleQRCode) GenerateWithWatermark(watermark []byte) ([]byte, error) {
-	qrCode, err := code.Generate()
-	if err != nil {
-		return nil, err
+// writeJSONError writes a structured JSON error response, setting the
+// status code before writing the body so it isn't silently locked to 200.
+func writeJSONError(writer http.ResponseWriter, status int, message string) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(status)
+	writer.Write(buildErrorResponse(message))
+}
+
+// parseHexColor parses a "#rrggbb" string into a color.Color. An empty
+// string returns a nil color, signaling that the caller should fall back to
+// the library default.
+func parseHexColor(hex string) (color.Color, error) {
+	if hex == "" {
+		return nil, nil
+	}
+
+	hex = strings.TrimPrefix(hex, "#")
+	if len(hex) != 6 {
+		return nil, fmt.Errorf("invalid color %q: expected a 6-digit hex value", hex)
 	}
 
-	qrCode, err = code.addWatermark(qrCode, watermark, code.Size)
+	rgb, err := strconv.ParseUint(hex, 16, 32)
 	if err != nil {
-		return nil, fmt.Errorf("could not add watermark to QR code: %v", err)
+		return nil, fmt.Errorf("invalid color %q: %v", hex, err)
 	}
 
-	return qrCode, nil
+	return color.RGBA{R: uint8(rgb >> 16), G: uint8(rgb >> 8), B: uint8(rgb), A: 0xff}, nil
 }
 
-// addWatermark adds a watermark to a QR code, centered in the middle of the QR code
-func (code *simpleQRCode) addWatermark(qrCode []byte, watermarkData []byte, size int) ([]byte, error) {
-	qrCodeData, err := png.Decode(bytes.NewBuffer(qrCode))
+// buildQRCodeFromRequest turns a generateRequest into a qrcode.SimpleQRCode,
+// validating and resolving the error-correction level and colors.
+func buildQRCodeFromRequest(req generateRequest) (qrcode.SimpleQRCode, error) {
+	if req.Content == "" {
+		return qrcode.SimpleQRCode{}, errors.New("could not determine the desired QR code content")
+	}
+
+	if req.Size <= 0 {
+		return qrcode.SimpleQRCode{}, errors.New("could not determine the desired QR code size")
+	}
+
+	level, err := qrcode.ParseRecoveryLevel(req.ErrorCorrection)
 	if err != nil {
-		return nil, fmt.Errorf("could not decode QR code: %v", err)
+		return qrcode.SimpleQRCode{}, err
 	}
 
-	watermarkImage, err := png.Decode(bytes.NewBuffer(watermarkData))
+	foreground, err := parseHexColor(req.Foreground)
 	if err != nil {
-		return nil, fmt.Errorf("could not decode watermark: %v", err)
+		return qrcode.SimpleQRCode{}, err
 	}
 
-	// Determine the offset to center the watermark on the QR code
-	offset := image.Pt(((size / 2) - 32), ((size / 2) - 32))
+	background, err := parseHexColor(req.Background)
+	if err != nil {
+		return qrcode.SimpleQRCode{}, err
+	}
 
-	watermarkImageBounds := qrCodeData.Bounds()
-	m := image.NewRGBA(watermarkImageBounds)
+	format := qrcode.Format(req.OutputFormat)
+	if _, err := qrcode.NewEncoder(format, req.Quality); err != nil {
+		return qrcode.SimpleQRCode{}, err
+	}
 
-	// Center the watermark over the QR code
-	draw.Draw(m, watermarkImageBounds, qrCodeData, image.Point{}, draw.Src)
-	draw.Draw(
-		m,
-		watermarkImage.Bounds().Add(offset),
-		watermarkImage,
-		image.Point{},
-		draw.Over,
-	)
+	return qrcode.SimpleQRCode{
+		Content:     req.Content,
+		Size:        req.Size,
+		NoMargin:    req.NoMargin,
+		EncodeLevel: level,
+		Foreground:  foreground,
+		Background:  background,
+		Format:      format,
+		JPEGQuality: req.Quality,
+	}, nil
+}
 
-	watermarkedQRCode := bytes.NewBuffer(nil)
-	png.Encode(watermarkedQRCode, m)
+// writeQRCode writes a generated QR code to the response, setting the
+// Content-Type and Content-Disposition headers to match its output format.
+func writeQRCode(writer http.ResponseWriter, format qrcode.Format, quality int, codeData []byte) {
+	encoder, err := qrcode.NewEncoder(format, quality)
+	if err != nil {
+		// buildQRCodeFromRequest/the form handler already validated the
+		// format, so this can only happen if encoding itself changes it.
+		writeJSONError(writer, http.StatusInternalServerError, err.Error())
+		return
+	}
 
-	return watermarkedQRCode.Bytes(), nil
+	writer.Header().Set("Content-Type", encoder.ContentType())
+	writer.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="qrcode.%s"`, encoder.Extension()))
+	writer.Write(codeData)
 }
 
-// resizeWatermark resizes a watermark image to the desired width and height
-func resizeWatermark(watermark io.Reader, width uint) ([]byte, error) {
-	decodedImage, err := png.Decode(watermark)
+// buildWatermarkOptions parses the watermark styling parameters (scale,
+// padding, shape, opacity) from a request's form values.
+func buildWatermarkOptions(request *http.Request) (qrcode.WatermarkOptions, error) {
+	shape, err := qrcode.ParseWatermarkShape(request.FormValue("watermark_shape"))
 	if err != nil {
-		return nil, fmt.Errorf("could not decode watermark image: %v", err)
+		return qrcode.WatermarkOptions{}, err
 	}
 
-	m := resize.Resize(width, 0, decodedImage, resize.Lanczos3)
-	resized := bytes.NewBuffer(nil)
-	png.Encode(resized, m)
+	scale, _ := strconv.ParseFloat(request.FormValue("watermark_scale"), 64)
+	padding, _ := strconv.Atoi(request.FormValue("watermark_padding"))
+	opacity, err := strconv.ParseFloat(request.FormValue("watermark_opacity"), 64)
+	if err != nil {
+		// Not supplied (or unparseable): leave the sentinel so
+		// buildWatermarkOverlay applies its own default rather than
+		// silently treating an explicit "0" opacity the same way.
+		opacity = math.NaN()
+	}
 
-	return resized.Bytes(), nil
+	return qrcode.WatermarkOptions{
+		Scale:   scale,
+		Padding: padding,
+		Shape:   shape,
+		Opacity: opacity,
+	}, nil
 }
 
 // uploadFile uploads an image file to be used as a watermark for a QR code
 func uploadFile(file multipart.File) ([]byte, error) {
 	buf := bytes.NewBuffer(nil)
-	if _, err := io.Copy(buf, file); err != nil {
+	if _, err := buf.ReadFrom(file); err != nil {
 		return nil, fmt.Errorf("could not upload file. %v", err)
 	}
 
 	return buf.Bytes(), nil
 }
 
-// buildErrorResponse is a small utility function to simplify returning a JSON response
-// to be returned to the user when an error has occurred
-func buildErrorResponse(message string) []byte {
-	responseData := make(map[string]string)
-	responseData["error"] = message
+// handleJSONRequest serves /generate requests whose body is the
+// generateRequest JSON shape rather than a multipart form. Watermarking is
+// not available on this path since it requires uploading a file.
+func handleJSONRequest(writer http.ResponseWriter, request *http.Request) {
+	var req generateRequest
+	if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+		writeJSONError(writer, http.StatusBadRequest, fmt.Sprintf("Could not parse request body: %v", err))
+		return
+	}
 
-	response, err := json.Marshal(responseData)
+	qrCode, err := buildQRCodeFromRequest(req)
 	if err != nil {
-		log.Fatalln("Could not generate error message.")
+		writeJSONError(writer, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	return response
+	codeData, err := qrCode.Generate()
+	if err != nil {
+		writeJSONError(writer, http.StatusBadRequest, fmt.Sprintf("Could not generate QR code. %v", err))
+		return
+	}
+
+	writeQRCode(writer, qrCode.Format, qrCode.JPEGQuality, codeData)
 }
 
 func handleRequest(writer http.ResponseWriter, request *http.Request) {
+	if strings.Contains(request.Header.Get("Content-Type"), "application/json") {
+		handleJSONRequest(writer, request)
+		return
+	}
+
 	request.ParseMultipartForm(10 << 20)
 	var size, url string = request.FormValue("size"), request.FormValue("url")
 	var codeData []byte
 
 	if url == "" {
-		writer.Write(buildErrorResponse("Could not determine the desired QR code content."))
-		writer.WriteHeader(400)
+		writeJSONError(writer, http.StatusBadRequest, "Could not determine the desired QR code content.")
 		return
 	}
 
 	qrCodeSize, err := strconv.Atoi(size)
 	if err != nil || size == "" {
-		writer.Write(buildErrorResponse(fmt.Sprint("Could not determine the desired QR code size:", err)))
-		writer.WriteHeader(400)
+		writeJSONError(writer, http.StatusBadRequest, fmt.Sprint("Could not determine the desired QR code size:", err))
+		return
+	}
+
+	level, err := qrcode.ParseRecoveryLevel(request.FormValue("error_correction"))
+	if err != nil {
+		writeJSONError(writer, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	qrCode := simpleQRCode{Content: url, Size: qrCodeSize}
+	format := qrcode.Format(request.FormValue("format"))
+	quality, _ := strconv.Atoi(request.FormValue("quality"))
+	if _, err := qrcode.NewEncoder(format, quality); err != nil {
+		writeJSONError(writer, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	qrCode := qrcode.SimpleQRCode{
+		Content:     url,
+		Size:        qrCodeSize,
+		EncodeLevel: level,
+		Format:      format,
+		JPEGQuality: quality,
+	}
 
 	watermarkFile, _, err := request.FormFile("watermark")
 	if err != nil && errors.Is(err, http.ErrMissingFile) {
 		fmt.Println("Watermark image was not uploaded or could not be retrieved. Reason: ", err)
 		codeData, err = qrCode.Generate()
 		if err != nil {
-			writer.Write(buildErrorResponse(fmt.Sprintf("Could not generate QR code. %v", err)))
-			writer.WriteHeader(400)
+			writeJSONError(writer, http.StatusBadRequest, fmt.Sprintf("Could not generate QR code. %v", err))
 			return
 		}
-		writer.Header().Add("Content-Type", "image/png")
-		writer.Write(codeData)
+		writeQRCode(writer, qrCode.Format, qrCode.JPEGQuality, codeData)
 		return
 	}
 
 	watermark, err := uploadFile(watermarkFile)
 	if err != nil {
-		writer.Write(buildErrorResponse(fmt.Sprint("Could not upload the watermark image.", err)))
-		writer.WriteHeader(400)
+		writeJSONError(writer, http.StatusBadRequest, fmt.Sprint("Could not upload the watermark image.", err))
 		return
 	}
 
 	contentType := http.DetectContentType(watermark)
-	if contentType != "image/png" {
-		response := buildErrorResponse(fmt.Sprintf("Provided watermark image is a %s not a PNG. %v.", err, contentType))
-		writer.Write(response)
-		writer.WriteHeader(400)
+	if contentType != "image/png" && contentType != "image/jpeg" && contentType != "image/gif" {
+		writeJSONError(writer, http.StatusBadRequest, fmt.Sprintf("Provided watermark image is a %s, not a PNG, JPEG or GIF.", contentType))
 		return
 	}
 
-	watermark, err = resizeWatermark(bytes.NewBuffer(watermark), WATERMARK_WIDTH)
+	if format == qrcode.FormatSVG && contentType != "image/png" {
+		writeJSONError(writer, http.StatusBadRequest, fmt.Sprintf("SVG output only supports a PNG watermark, not %s.", contentType))
+		return
+	}
+
+	watermarkOptions, err := buildWatermarkOptions(request)
 	if err != nil {
-		writer.Write(buildErrorResponse("Could not resize the watermark image."))
-		writer.WriteHeader(400)
+		writeJSONError(writer, http.StatusBadRequest, err.Error())
 		return
 	}
+	qrCode.Watermark = watermarkOptions
+
+	// Watermarking requires qrcode.Highest error correction so enough of the
+	// QR code remains scannable once the logo is pasted over it.
+	qrCode.EncodeLevel = qrcode.Highest
 
 	codeData, err = qrCode.GenerateWithWatermark(watermark)
 	if err != nil {
-		response := buildErrorResponse(fmt.Sprintf("Could not generate QR code with the watermark image. %v", err))
-		writer.Write(response)
-		writer.WriteHeader(400)
+		writeJSONError(writer, http.StatusBadRequest, fmt.Sprintf("Could not generate QR code with the watermark image. %v", err))
 		return
 	}
 
-	writer.Header().Add("Content-Type", "image/png")
-	writer.Write(codeData)
+	writeQRCode(writer, qrCode.Format, qrCode.JPEGQuality, codeData)
 }
 
 func main() {
@@ -199,6 +292,11 @@ func main() {
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/generate", handleRequest)
+	mux.HandleFunc("/generate/chunked", handleGenerateChunked)
+	mux.HandleFunc("/decode/chunked", handleDecodeChunked)
+	mux.HandleFunc("/encode/file", handleEncodeFile)
+	mux.HandleFunc("/decode/file", handleDecodeFile)
+	mux.HandleFunc("/generate/otp", handleGenerateOTP)
 
 	log.Printf("Starting server on %s", *addr)
 	err := http.ListenAndServe(*addr, mux)
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/settermjd/go-qr-code-generator/otpauth"
+	"github.com/settermjd/go-qr-code-generator/qrcode"
+)
+
+// DefaultOTPDigits and DefaultOTPPeriod are used when the client doesn't
+// specify either explicitly.
+const DefaultOTPDigits = 6
+const DefaultOTPPeriod = 30
+
+// OTPQRCodeSize is the pixel size of a generated OTP QR code.
+const OTPQRCodeSize = 256
+
+// handleGenerateOTP builds an otpauth:// URI from the request parameters,
+// generating a random secret when one isn't supplied, and renders it as a
+// QR code suitable for Google Authenticator / Authy.
+func handleGenerateOTP(writer http.ResponseWriter, request *http.Request) {
+	request.ParseMultipartForm(10 << 20)
+
+	label := request.FormValue("label")
+	if label == "" {
+		writeJSONError(writer, http.StatusBadRequest, "Could not determine the desired account label.")
+		return
+	}
+
+	digits, err := strconv.Atoi(request.FormValue("digits"))
+	if err != nil || digits == 0 {
+		digits = DefaultOTPDigits
+	}
+	if digits != 6 && digits != 8 {
+		writeJSONError(writer, http.StatusBadRequest, "digits must be 6 or 8.")
+		return
+	}
+
+	period, err := strconv.Atoi(request.FormValue("period"))
+	if err != nil || period <= 0 {
+		period = DefaultOTPPeriod
+	}
+
+	algorithm, err := otpauth.ParseAlgorithm(request.FormValue("algorithm"))
+	if err != nil {
+		writeJSONError(writer, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	secret := request.FormValue("secret")
+	if secret == "" {
+		secret, err = otpauth.NewSecret()
+		if err != nil {
+			writeJSONError(writer, http.StatusInternalServerError, err.Error())
+			return
+		}
+	} else {
+		secret = otpauth.NormalizeSecret(secret)
+	}
+
+	uri := otpauth.URI{
+		Label:     label,
+		Issuer:    request.FormValue("issuer"),
+		Secret:    secret,
+		Digits:    digits,
+		Period:    period,
+		Algorithm: algorithm,
+	}
+
+	// Use the highest error correction so the code stays scannable with a
+	// small issuer logo watermark centered on it.
+	qrCode := qrcode.SimpleQRCode{Content: uri.String(), Size: OTPQRCodeSize, EncodeLevel: qrcode.Highest}
+	codeData, err := qrCode.Generate()
+	if err != nil {
+		writeJSONError(writer, http.StatusInternalServerError, fmt.Sprintf("Could not generate QR code. %v", err))
+		return
+	}
+
+	if strings.Contains(request.Header.Get("Accept"), "application/json") {
+		writer.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(writer).Encode(map[string]string{
+			"secret": secret,
+			"image":  base64.StdEncoding.EncodeToString(codeData),
+		})
+		return
+	}
+
+	writer.Header().Set("X-OTP-Secret", secret)
+	writeQRCode(writer, qrCode.Format, qrCode.JPEGQuality, codeData)
+}
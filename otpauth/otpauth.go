@@ -0,0 +1,97 @@
+// Package otpauth builds otpauth:// URIs of the form used by Google
+// Authenticator and compatible TOTP apps, and generates the random secrets
+// they're built from.
+package otpauth
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Algorithm is the HMAC hash algorithm a TOTP code is derived with.
+type Algorithm string
+
+// The algorithms supported by the otpauth Key URI Format.
+const (
+	SHA1   Algorithm = "SHA1"
+	SHA256 Algorithm = "SHA256"
+	SHA512 Algorithm = "SHA512"
+)
+
+// ParseAlgorithm maps an algorithm name to an Algorithm. An empty string
+// resolves to SHA1, the otpauth format's default.
+func ParseAlgorithm(name string) (Algorithm, error) {
+	switch strings.ToUpper(name) {
+	case "", "SHA1":
+		return SHA1, nil
+	case "SHA256":
+		return SHA256, nil
+	case "SHA512":
+		return SHA512, nil
+	default:
+		return "", fmt.Errorf("unsupported TOTP algorithm: %q", name)
+	}
+}
+
+// NewSecret generates a random 20-byte TOTP secret, base32-encoded without
+// padding as expected by authenticator apps.
+func NewSecret() (string, error) {
+	buf := make([]byte, 20)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("could not generate TOTP secret: %v", err)
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf), nil
+}
+
+// NormalizeSecret puts a client-supplied secret into the base32 form
+// otpauth expects. If secret is already valid base32 (ignoring case and
+// the spaces authenticator apps commonly use to display it in groups),
+// it's normalized to upper-case with no padding; otherwise secret is
+// treated as a raw passphrase and base32-encoded itself, so a
+// client-supplied secret ends up just as valid in the resulting otpauth
+// URI as one from NewSecret.
+func NormalizeSecret(secret string) string {
+	compact := strings.TrimRight(strings.ToUpper(strings.ReplaceAll(secret, " ", "")), "=")
+	if _, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(compact); err == nil {
+		return compact
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte(secret))
+}
+
+// URI describes a TOTP account to be rendered as an otpauth:// URI.
+type URI struct {
+	Label     string
+	Issuer    string
+	Secret    string
+	Digits    int
+	Period    int
+	Algorithm Algorithm
+}
+
+// String renders u as an otpauth://totp/... URI per the Key URI Format.
+func (u URI) String() string {
+	label := u.Label
+	if u.Issuer != "" {
+		label = fmt.Sprintf("%s:%s", u.Issuer, u.Label)
+	}
+
+	values := url.Values{}
+	values.Set("secret", u.Secret)
+	if u.Issuer != "" {
+		values.Set("issuer", u.Issuer)
+	}
+	values.Set("digits", fmt.Sprint(u.Digits))
+	values.Set("period", fmt.Sprint(u.Period))
+	values.Set("algorithm", string(u.Algorithm))
+
+	uri := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + label,
+		RawQuery: values.Encode(),
+	}
+	return uri.String()
+}
@@ -0,0 +1,158 @@
+// Package qrcode contains the core QR code generation logic used by the
+// HTTP handlers in main, including colour/recovery-level configuration and
+// watermarking. It exists so main.go can stay focused on request handling.
+package qrcode
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+
+	goqrcode "github.com/skip2/go-qrcode"
+)
+
+// RecoveryLevel is the error-correction level of a generated QR code. It is
+// an alias of go-qrcode's own type so callers of this package don't need to
+// import the underlying library directly.
+type RecoveryLevel = goqrcode.RecoveryLevel
+
+// The four error-correction levels supported by the underlying QR code
+// standard, re-exported for convenience.
+const (
+	Low     RecoveryLevel = goqrcode.Low
+	Medium  RecoveryLevel = goqrcode.Medium
+	High    RecoveryLevel = goqrcode.High
+	Highest RecoveryLevel = goqrcode.Highest
+)
+
+// ParseRecoveryLevel maps the names used in the public API (low, medium,
+// high, highest) to a RecoveryLevel. An empty string resolves to Medium,
+// matching the generator's previous hardcoded default.
+func ParseRecoveryLevel(name string) (RecoveryLevel, error) {
+	switch name {
+	case "", "medium":
+		return Medium, nil
+	case "low":
+		return Low, nil
+	case "high":
+		return High, nil
+	case "highest":
+		return Highest, nil
+	default:
+		return Medium, fmt.Errorf("unknown error correction level: %q", name)
+	}
+}
+
+// Fits reports whether content can be encoded as a single QR code at the
+// given error-correction level without exceeding the version-40 capacity
+// limit of the QR code standard.
+func Fits(content string, level RecoveryLevel) bool {
+	_, err := goqrcode.New(content, level)
+	return err == nil
+}
+
+// SimpleQRCode describes a QR code to be generated.
+type SimpleQRCode struct {
+	Content string
+	Size    int
+	// NoMargin, when true, omits the standard quiet-zone border around the
+	// QR code; the zero value keeps it, matching the generator's previous
+	// (and library default) behavior. Codes without a quiet zone can fail
+	// to scan, so this should only be set when the caller is confident the
+	// surrounding layout already provides one.
+	NoMargin bool
+	// EncodeLevel controls how much of the QR code can be obscured (e.g.
+	// by a watermark) and still be scannable.
+	EncodeLevel RecoveryLevel
+	// Foreground and Background override the QR code's module and
+	// background colors. Either may be left nil to use the library's
+	// defaults (black on white).
+	Foreground color.Color
+	Background color.Color
+	// Format selects the output image encoding. An empty value defaults
+	// to FormatPNG.
+	Format Format
+	// JPEGQuality is only used when Format is FormatJPEG; a value <= 0
+	// falls back to image/jpeg's default quality.
+	JPEGQuality int
+	// Watermark controls how a watermark image is sized, masked and
+	// blended onto the QR code by GenerateWithWatermark.
+	Watermark WatermarkOptions
+}
+
+// image renders the QR code described by code, honoring the requested
+// error-correction level and colors, without applying a watermark or
+// encoding it to an output format yet.
+func (code *SimpleQRCode) image() (image.Image, error) {
+	qr, err := goqrcode.New(code.Content, code.EncodeLevel)
+	if err != nil {
+		return nil, fmt.Errorf("could not generate a QR code: %v", err)
+	}
+
+	qr.DisableBorder = code.NoMargin
+
+	if code.Foreground != nil {
+		qr.ForegroundColor = code.Foreground
+	}
+	if code.Background != nil {
+		qr.BackgroundColor = code.Background
+	}
+
+	return qr.Image(code.Size), nil
+}
+
+// encode encodes img using the format and JPEG quality requested on code.
+func (code *SimpleQRCode) encode(img image.Image) ([]byte, error) {
+	encoder, err := NewEncoder(code.Format, code.JPEGQuality)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := encoder.Encode(buf, img); err != nil {
+		return nil, fmt.Errorf("could not encode QR code: %v", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Generate generates a QR code using the value of SimpleQRCode.Content,
+// honoring the requested error-correction level, colors and output format.
+// When Format is FormatSVG, the result is a true vector document rather
+// than a rasterized image; see generateSVG.
+func (code *SimpleQRCode) Generate() ([]byte, error) {
+	if code.Format == FormatSVG {
+		return code.generateSVG(nil)
+	}
+
+	img, err := code.image()
+	if err != nil {
+		return nil, err
+	}
+
+	return code.encode(img)
+}
+
+// GenerateWithWatermark generates a QR code using the value of
+// SimpleQRCode.Content and adds a watermark to it, centered in the middle
+// of the QR code, using the supplied watermark image data. When Format is
+// FormatSVG, the QR code stays vector and the watermark is embedded as its
+// own <image> element rather than being baked into a rasterized code.
+func (code *SimpleQRCode) GenerateWithWatermark(watermark []byte) ([]byte, error) {
+	if code.Format == FormatSVG {
+		return code.generateSVG(watermark)
+	}
+
+	img, err := code.image()
+	if err != nil {
+		return nil, err
+	}
+
+	watermarked, err := code.addWatermark(img, watermark)
+	if err != nil {
+		return nil, fmt.Errorf("could not add watermark to QR code: %v", err)
+	}
+
+	return code.encode(watermarked)
+}
@@ -0,0 +1,85 @@
+package qrcode
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+
+	goqrcode "github.com/skip2/go-qrcode"
+)
+
+// generateSVG renders the QR code described by code as a true vector SVG
+// document — one <rect> per dark module, rather than a rasterized image
+// wrapped in an <svg> container — so the code itself stays crisp at any
+// scale. If watermarkData is non-empty, the watermark (including its
+// padding halo and shape mask) is rendered separately and embedded as its
+// own <image> element, rather than being baked into a rasterized QR code.
+func (code *SimpleQRCode) generateSVG(watermarkData []byte) ([]byte, error) {
+	qr, err := goqrcode.New(code.Content, code.EncodeLevel)
+	if err != nil {
+		return nil, fmt.Errorf("could not generate a QR code: %v", err)
+	}
+	qr.DisableBorder = code.NoMargin
+
+	modules := qr.Bitmap()
+	if len(modules) == 0 {
+		return nil, fmt.Errorf("could not generate a QR code: empty module grid")
+	}
+	n := len(modules)
+
+	foreground := "#000000"
+	if code.Foreground != nil {
+		foreground = hexString(code.Foreground)
+	}
+	background := "#ffffff"
+	if code.Background != nil {
+		background = hexString(code.Background)
+	}
+
+	var svgBody strings.Builder
+	fmt.Fprintf(&svgBody,
+		`<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" viewBox="0 0 %d %d" width="%d" height="%d" shape-rendering="crispEdges">`,
+		n, n, code.Size, code.Size,
+	)
+	fmt.Fprintf(&svgBody, `<rect width="%d" height="%d" fill="%s"/>`, n, n, background)
+
+	for y, row := range modules {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&svgBody, `<rect x="%d" y="%d" width="1" height="1" fill="%s"/>`, x, y, foreground)
+			}
+		}
+	}
+
+	if len(watermarkData) > 0 {
+		overlay, placement, err := buildWatermarkOverlay(image.Rect(0, 0, n, n), watermarkData, code.Watermark, code.Background)
+		if err != nil {
+			return nil, fmt.Errorf("could not add watermark to QR code: %v", err)
+		}
+
+		var overlayPNG bytes.Buffer
+		if err := png.Encode(&overlayPNG, overlay); err != nil {
+			return nil, fmt.Errorf("could not encode watermark overlay: %v", err)
+		}
+
+		fmt.Fprintf(&svgBody,
+			`<image x="%d" y="%d" width="%d" height="%d" xlink:href="data:image/png;base64,%s"/>`,
+			placement.Min.X, placement.Min.Y, placement.Dx(), placement.Dy(),
+			base64.StdEncoding.EncodeToString(overlayPNG.Bytes()),
+		)
+	}
+
+	svgBody.WriteString(`</svg>`)
+
+	return []byte(svgBody.String()), nil
+}
+
+// hexString renders c as a "#rrggbb" string.
+func hexString(c color.Color) string {
+	r, g, b, _ := c.RGBA()
+	return fmt.Sprintf("#%02x%02x%02x", uint8(r>>8), uint8(g>>8), uint8(b>>8))
+}
@@ -0,0 +1,243 @@
+package qrcode
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"  // register GIF decoding with image.Decode
+	_ "image/jpeg" // register JPEG decoding with image.Decode
+	"math"
+
+	"github.com/nfnt/resize"
+)
+
+// WatermarkShape masks a watermark (and its padding halo) to a shape before
+// it's drawn onto a QR code.
+type WatermarkShape string
+
+// The watermark shapes supported by addWatermark.
+const (
+	ShapeSquare  WatermarkShape = "square"
+	ShapeCircle  WatermarkShape = "circle"
+	ShapeRounded WatermarkShape = "rounded"
+)
+
+// ParseWatermarkShape maps a shape name to a WatermarkShape. An empty
+// string resolves to ShapeSquare, matching the generator's previous
+// behavior of simply pasting the logo.
+func ParseWatermarkShape(name string) (WatermarkShape, error) {
+	switch WatermarkShape(name) {
+	case "", ShapeSquare:
+		return ShapeSquare, nil
+	case ShapeCircle:
+		return ShapeCircle, nil
+	case ShapeRounded:
+		return ShapeRounded, nil
+	default:
+		return "", fmt.Errorf("unknown watermark shape: %q", name)
+	}
+}
+
+// DefaultWatermarkScale is the fraction of the QR code's width a watermark
+// occupies when WatermarkOptions.Scale is left at zero.
+const DefaultWatermarkScale = 0.2
+
+// roundedCornerFraction is the fraction of the shorter side used as the
+// corner radius for ShapeRounded.
+const roundedCornerFraction = 0.2
+
+// WatermarkOptions controls how a watermark image is sized, masked and
+// blended onto a QR code.
+type WatermarkOptions struct {
+	// Scale is the fraction of the QR code's width the watermark should
+	// occupy. Defaults to DefaultWatermarkScale when zero.
+	Scale float64
+	// Padding is a halo drawn behind the watermark so the QR code's finder
+	// patterns aren't obscured right up to the logo's edge, in pixels for
+	// raster output or in modules for vector SVG output.
+	Padding int
+	// Shape masks the watermark and its padding halo to this shape.
+	// Defaults to ShapeSquare when empty.
+	Shape WatermarkShape
+	// Opacity is the alpha blended into the QR code, from 0.0 (invisible)
+	// to 1.0 (opaque). Because 0.0 is itself a meaningful value in that
+	// range, the zero value isn't used to mean "not supplied" — set
+	// Opacity to math.NaN() to get the default of 1.0.
+	Opacity float64
+}
+
+// addWatermark decodes and scales watermarkData relative to qrImage's
+// actual bounds (rather than the requested size, since go-qrcode's output
+// can differ once quiet zones are accounted for), then draws it, centered,
+// directly onto a copy of qrImage.
+func (code *SimpleQRCode) addWatermark(qrImage image.Image, watermarkData []byte) (image.Image, error) {
+	overlay, placement, err := buildWatermarkOverlay(qrImage.Bounds(), watermarkData, code.Watermark, code.Background)
+	if err != nil {
+		return nil, err
+	}
+
+	qrBounds := qrImage.Bounds()
+	m := image.NewRGBA(qrBounds)
+	draw.Draw(m, qrBounds, qrImage, image.Point{}, draw.Src)
+	draw.Draw(m, placement, overlay, image.Point{}, draw.Over)
+
+	return m, nil
+}
+
+// buildWatermarkOverlay decodes and scales watermarkData relative to
+// bounds (e.g. a QR code's raster bounds, or its module grid for vector
+// SVG output), and renders it — along with its padding halo and shape mask
+// — onto its own transparent RGBA image. It returns that overlay and the
+// rectangle, relative to bounds, it should be drawn at, so callers can
+// either composite it onto a raster QR code or embed it as a standalone
+// <image> element next to a vector one.
+func buildWatermarkOverlay(bounds image.Rectangle, watermarkData []byte, opts WatermarkOptions, background color.Color) (image.Image, image.Rectangle, error) {
+	watermarkImage, _, err := image.Decode(bytes.NewReader(watermarkData))
+	if err != nil {
+		return nil, image.Rectangle{}, fmt.Errorf("could not decode watermark: %v", err)
+	}
+
+	scale := opts.Scale
+	if math.IsNaN(scale) || scale <= 0 {
+		scale = DefaultWatermarkScale
+	} else if scale > 1 {
+		scale = 1
+	}
+	opacity := opts.Opacity
+	switch {
+	case math.IsNaN(opacity):
+		opacity = 1.0
+	case opacity < 0:
+		opacity = 0
+	case opacity > 1:
+		opacity = 1
+	}
+
+	// Padding is attacker-controlled (it comes straight from a request
+	// parameter); bound it to half the QR code's width so the overlay stays
+	// within about twice the QR code's size and can't force an oversized
+	// allocation. A halo at that bound still extends past the QR code.
+	padding := opts.Padding
+	if maxPadding := bounds.Dx() / 2; padding > maxPadding {
+		padding = maxPadding
+	}
+
+	logoWidth := uint(float64(bounds.Dx()) * scale)
+	if logoWidth < 1 {
+		logoWidth = 1
+	}
+	watermarkImage = resize.Resize(logoWidth, 0, watermarkImage, resize.Lanczos3)
+	logoBounds := watermarkImage.Bounds()
+
+	haloBounds := image.Rect(0, 0, logoBounds.Dx()+2*padding, logoBounds.Dy()+2*padding)
+	overlay := image.NewRGBA(haloBounds)
+
+	if padding > 0 {
+		// The halo is drawn in the QR code's own background color (white
+		// by default) so it blends in rather than always standing out as
+		// a white box on a custom-colored code.
+		haloColor := color.Color(color.White)
+		if background != nil {
+			haloColor = background
+		}
+
+		draw.DrawMask(
+			overlay,
+			haloBounds,
+			image.NewUniform(haloColor),
+			image.Point{},
+			watermarkMask(haloBounds, opts.Shape, 1.0),
+			image.Point{},
+			draw.Over,
+		)
+	}
+
+	logoOffsetInOverlay := image.Pt(padding, padding)
+	draw.DrawMask(
+		overlay,
+		logoBounds.Add(logoOffsetInOverlay),
+		watermarkImage,
+		logoBounds.Min,
+		watermarkMask(logoBounds, opts.Shape, opacity),
+		logoBounds.Min,
+		draw.Over,
+	)
+
+	center := image.Pt(bounds.Min.X+bounds.Dx()/2, bounds.Min.Y+bounds.Dy()/2)
+	placement := haloBounds.Add(image.Pt(center.X-haloBounds.Dx()/2, center.Y-haloBounds.Dy()/2))
+
+	return overlay, placement, nil
+}
+
+// watermarkMask builds an alpha mask covering bounds, shaped per shape and
+// scaled uniformly by opacity.
+func watermarkMask(bounds image.Rectangle, shape WatermarkShape, opacity float64) image.Image {
+	if opacity > 1 {
+		opacity = 1
+	} else if opacity < 0 {
+		opacity = 0
+	}
+	alpha := uint8(255 * opacity)
+
+	mask := image.NewAlpha(bounds)
+	switch shape {
+	case ShapeCircle:
+		rx, ry := bounds.Dx()/2, bounds.Dy()/2
+		cx, cy := bounds.Min.X+rx, bounds.Min.Y+ry
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				dx, dy := x-cx, y-cy
+				if dx*dx*ry*ry+dy*dy*rx*rx <= rx*rx*ry*ry {
+					mask.SetAlpha(x, y, color.Alpha{A: alpha})
+				}
+			}
+		}
+	case ShapeRounded:
+		r := int(float64(minInt(bounds.Dx(), bounds.Dy())) * roundedCornerFraction)
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				if insideRoundedRect(x, y, bounds, r) {
+					mask.SetAlpha(x, y, color.Alpha{A: alpha})
+				}
+			}
+		}
+	default: // ShapeSquare and anything unrecognized
+		draw.Draw(mask, bounds, image.NewUniform(color.Alpha{A: alpha}), image.Point{}, draw.Src)
+	}
+
+	return mask
+}
+
+// insideRoundedRect reports whether (x, y) falls within bounds once its
+// four corners have been rounded off with radius r.
+func insideRoundedRect(x, y int, bounds image.Rectangle, r int) bool {
+	minX, minY := bounds.Min.X, bounds.Min.Y
+	maxX, maxY := bounds.Max.X-1, bounds.Max.Y-1
+
+	inCircle := func(cx, cy int) bool {
+		dx, dy := x-cx, y-cy
+		return dx*dx+dy*dy <= r*r
+	}
+
+	switch {
+	case x < minX+r && y < minY+r:
+		return inCircle(minX+r, minY+r)
+	case x > maxX-r && y < minY+r:
+		return inCircle(maxX-r, minY+r)
+	case x < minX+r && y > maxY-r:
+		return inCircle(minX+r, maxY-r)
+	case x > maxX-r && y > maxY-r:
+		return inCircle(maxX-r, maxY-r)
+	default:
+		return true
+	}
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,136 @@
+// Package qrstream splits payloads that are too large for a single QR code
+// into a series of smaller chunks, and reassembles them again on the
+// decoding side. Each chunk carries a small JSON envelope identifying which
+// message it belongs to and where it sits in the sequence, so a decoder can
+// receive frames out of order (or with duplicates) and still recover the
+// original bytes.
+package qrstream
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// MaxTotal bounds how many chunks a single message may be split into, so
+// AssembleChunks can't be tricked into buffering an unbounded number of
+// chunks for a message that claims a huge total.
+const MaxTotal = 10000
+
+// Chunk is the JSON envelope a chunk of a split message is wrapped in
+// before being encoded as a QR code.
+type Chunk struct {
+	ID      string `json:"id"`
+	Index   int    `json:"index"`
+	Total   int    `json:"total"`
+	Payload string `json:"payload"`
+}
+
+// SplitIntoChunks splits data into consecutive slices of at most chunkSize
+// bytes each. The returned slices are plain byte slices; use NewEnvelopes to
+// wrap them in a Chunk envelope ready for encoding as a QR code.
+func SplitIntoChunks(data []byte, chunkSize int) [][]byte {
+	if chunkSize <= 0 || len(data) == 0 {
+		return [][]byte{}
+	}
+
+	chunks := make([][]byte, 0, (len(data)+chunkSize-1)/chunkSize)
+	for offset := 0; offset < len(data); offset += chunkSize {
+		end := offset + chunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		chunks = append(chunks, data[offset:end])
+	}
+
+	return chunks
+}
+
+// NewEnvelopes wraps each raw chunk produced by SplitIntoChunks in a Chunk
+// envelope sharing id, and marshals each envelope to JSON so it is ready to
+// be used as the content of a QR code.
+func NewEnvelopes(id string, rawChunks [][]byte) ([][]byte, error) {
+	total := len(rawChunks)
+	envelopes := make([][]byte, 0, total)
+
+	for index, raw := range rawChunks {
+		envelope, err := json.Marshal(Chunk{
+			ID:      id,
+			Index:   index,
+			Total:   total,
+			Payload: base64.StdEncoding.EncodeToString(raw),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("could not build chunk envelope %d/%d: %v", index, total, err)
+		}
+		envelopes = append(envelopes, envelope)
+	}
+
+	return envelopes, nil
+}
+
+// AssembleChunks reassembles the original message from a set of decoded
+// Chunk envelopes, as produced by NewEnvelopes. Chunks may arrive in any
+// order and with duplicates, but must all share the same id, must account
+// for every index from 0 up to total-1, and must not claim a total over
+// MaxTotal.
+func AssembleChunks(chunks [][]byte) ([]byte, error) {
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("no chunks to assemble")
+	}
+
+	seen := make(map[int]Chunk)
+	var id string
+	var total int
+
+	for _, raw := range chunks {
+		var chunk Chunk
+		if err := json.Unmarshal(raw, &chunk); err != nil {
+			return nil, fmt.Errorf("could not parse chunk envelope: %v", err)
+		}
+
+		if chunk.Total <= 0 || chunk.Total > MaxTotal {
+			return nil, fmt.Errorf("chunk %q reports an invalid total of %d", chunk.ID, chunk.Total)
+		}
+
+		switch {
+		case total == 0: // first chunk; its total was validated above
+			id, total = chunk.ID, chunk.Total
+		case chunk.ID != id:
+			return nil, fmt.Errorf("chunk %q does not belong to message %q", chunk.ID, id)
+		case chunk.Total != total:
+			return nil, fmt.Errorf("chunk %q reports total %d, expected %d", chunk.ID, chunk.Total, total)
+		}
+
+		if chunk.Index < 0 || chunk.Index >= total {
+			return nil, fmt.Errorf("chunk %q has out-of-range index %d", chunk.ID, chunk.Index)
+		}
+
+		// Dedupe repeated frames: the first copy of an index wins.
+		if _, ok := seen[chunk.Index]; !ok {
+			seen[chunk.Index] = chunk
+		}
+	}
+
+	if len(seen) != total {
+		return nil, fmt.Errorf("have %d of %d chunks for message %q", len(seen), total, id)
+	}
+
+	indexes := make([]int, 0, total)
+	for index := range seen {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	assembled := make([]byte, 0)
+	for _, index := range indexes {
+		payload, err := base64.StdEncoding.DecodeString(seen[index].Payload)
+		if err != nil {
+			return nil, fmt.Errorf("could not decode chunk %d: %v", index, err)
+		}
+		assembled = append(assembled, payload...)
+	}
+
+	return assembled, nil
+}